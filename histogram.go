@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"math"
+	"time"
+)
+
+// Bucket layout for the latency histogram: 368 exponentially growing
+// buckets covering roughly 1µs to 60s at ~5% relative error per bucket
+// (1000ns * 1.05^368 ≈ 62.8s). Alongside the per-route
+// URLHighestResponse/URLLowestResponse min/max pair, which is still
+// tracked, this keeps a real distribution we can pull quantiles out of.
+const (
+	histogramBuckets = 368
+	histogramEpsilon = 0.05
+	histogramMinNs   = 1000.0 // 1µs
+)
+
+var histogramLogBase = math.Log(1 + histogramEpsilon)
+
+// latencyHistogram is a fixed-size, log-linear bucket histogram. Insertion
+// is O(1) and memory is bounded regardless of how many samples are seen.
+type latencyHistogram struct {
+	buckets [histogramBuckets]uint64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	h.buckets[bucketFor(d)]++
+}
+
+func (h *latencyHistogram) reset() {
+	h.buckets = [histogramBuckets]uint64{}
+}
+
+// clone returns a copy of h that shares no state with it, safe to read
+// after the lock guarding h has been released.
+func (h *latencyHistogram) clone() *latencyHistogram {
+	c := *h
+	return &c
+}
+
+func bucketFor(d time.Duration) int {
+	ns := float64(d.Nanoseconds())
+	if ns < histogramMinNs {
+		ns = histogramMinNs
+	}
+
+	idx := int(math.Log(ns/histogramMinNs) / histogramLogBase)
+	if idx >= histogramBuckets {
+		idx = histogramBuckets - 1
+	}
+	if idx < 0 {
+		idx = 0
+	}
+
+	return idx
+}
+
+// bucketBounds returns the [low, high) nanosecond range a bucket covers.
+func bucketBounds(i int) (float64, float64) {
+	low := histogramMinNs * math.Pow(1+histogramEpsilon, float64(i))
+	high := low * (1 + histogramEpsilon)
+	return low, high
+}
+
+// quantile returns the q-th quantile (0 < q <= 1) as a duration, using the
+// geometric midpoint of the bucket the quantile falls into.
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	var total uint64
+	for _, c := range h.buckets {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := q * float64(total)
+
+	var cumulative uint64
+	for i, c := range h.buckets {
+		cumulative += c
+		if float64(cumulative) >= target {
+			low, high := bucketBounds(i)
+			return time.Duration(math.Sqrt(low * high))
+		}
+	}
+
+	low, high := bucketBounds(histogramBuckets - 1)
+	return time.Duration(math.Sqrt(low * high))
+}
+
+// snapshot returns quantiles the caller asked for, keyed by a short label
+// ("p50", "p90", ...) for embedding directly into the data struct.
+func (h *latencyHistogram) snapshot(quantiles map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(quantiles))
+	for label, q := range quantiles {
+		out[label] = h.quantile(q).Seconds()
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrometheusHandler renders the current state in Prometheus text exposition
+// format, with OpenMetrics served instead when the caller's Accept header
+// asks for it. This lets stats plug into a standard scrape-based pipeline
+// alongside the existing JSON Data() output.
+func (mw *Stats) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openMetrics := wantsOpenMetrics(r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", prometheusContentType(openMetrics))
+
+		mw.mu.RLock()
+		defer mw.mu.RUnlock()
+
+		mw.writePrometheus(w, openMetrics)
+	})
+}
+
+func wantsOpenMetrics(accept string) bool {
+	return strings.Contains(accept, "application/openmetrics-text")
+}
+
+func prometheusContentType(openMetrics bool) string {
+	if openMetrics {
+		return "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	}
+	return "text/plain; version=0.0.4; charset=utf-8"
+}
+
+func (mw *Stats) writePrometheus(w io.Writer, openMetrics bool) {
+	routes := mw.routeShards.snapshot()
+
+	fmt.Fprintln(w, "# HELP http_requests_total Total number of HTTP requests.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for key, count := range mw.RequestLabelCounts {
+		parts := strings.SplitN(key, "|", 3)
+		method, code, path := parts[0], parts[1], parts[2]
+		fmt.Fprintf(w, "http_requests_total{method=%q,code=%q,path=%q} %d\n", method, code, path, count)
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Latency distribution of HTTP requests.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for path, hist := range routes.latencyHistogram {
+		var cumulative uint64
+		for i, count := range hist.buckets {
+			cumulative += count
+			_, high := bucketBounds(i)
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{path=%q,le=%q} %d\n", path, formatLe(high), cumulative)
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", path, cumulative)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{path=%q} %s\n", path, formatFloat(time.Duration(routes.requestLatencyNs[path]).Seconds()))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{path=%q} %d\n", path, routes.requestCounts[path])
+	}
+
+	fmt.Fprintln(w, "# HELP http_response_size_bytes_total Total bytes written in HTTP responses.")
+	fmt.Fprintln(w, "# TYPE http_response_size_bytes_total counter")
+	for path, size := range routes.responseSizeBytes {
+		fmt.Fprintf(w, "http_response_size_bytes_total{path=%q} %d\n", path, size)
+	}
+
+	fmt.Fprintln(w, "# HELP http_requests_by_status_class_total Total number of HTTP requests by status class.")
+	fmt.Fprintln(w, "# TYPE http_requests_by_status_class_total counter")
+	for class := 1; class <= 5; class++ {
+		fmt.Fprintf(w, "http_requests_by_status_class_total{class=\"%dxx\"} %d\n", class, mw.StatusClassCounts[class].Load())
+	}
+
+	fmt.Fprintln(w, "# HELP process_uptime_seconds Time since the process started.")
+	fmt.Fprintln(w, "# TYPE process_uptime_seconds gauge")
+	fmt.Fprintf(w, "process_uptime_seconds %s\n", formatFloat(time.Since(mw.Uptime).Seconds()))
+
+	fmt.Fprintln(w, "# HELP process_start_time_seconds Unix time the process started.")
+	fmt.Fprintln(w, "# TYPE process_start_time_seconds gauge")
+	fmt.Fprintf(w, "process_start_time_seconds %d\n", mw.Uptime.Unix())
+
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+// formatLe renders a histogram bucket's upper bound in the seconds unit
+// Prometheus duration metrics use, converting from the nanosecond bounds
+// the latencyHistogram tracks internally.
+func formatLe(upperNs float64) string {
+	return formatFloat(upperNs / 1e9)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
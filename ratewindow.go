@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// Rolling-bucket rate tracking: a ring of fixed-width time buckets holding
+// enough history to answer "what's the RPS/error-rate/throughput over the
+// last N seconds/minutes" without rescanning every request ever seen.
+const (
+	rateBucketWidth = 10 * time.Second
+	rateBucketCount = 360 // 360 * 10s = 1h of history
+)
+
+// rateWindows are the windows reported in data.RPS10s1m5m15m and friends.
+var rateWindows = [4]time.Duration{
+	10 * time.Second,
+	1 * time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+}
+
+type rateBucket struct {
+	requests  uint64
+	errors    uint64
+	bytes     uint64
+	latencyNs uint64
+}
+
+// rateRing advances one bucket every rateBucketWidth, dropping the oldest
+// bucket's counts out of the window as it goes.
+type rateRing struct {
+	mu      sync.Mutex
+	buckets [rateBucketCount]rateBucket
+	current int
+}
+
+func newRateRing() *rateRing {
+	return &rateRing{}
+}
+
+func (r *rateRing) advance() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.current = (r.current + 1) % rateBucketCount
+	r.buckets[r.current] = rateBucket{}
+}
+
+func (r *rateRing) record(isError bool, size int64, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := &r.buckets[r.current]
+	b.requests++
+	if isError {
+		b.errors++
+	}
+	if size > 0 {
+		b.bytes += uint64(size)
+	}
+	b.latencyNs += uint64(latency)
+}
+
+// sum totals the buckets covering the trailing window, clamped to the
+// amount of history the ring actually holds.
+func (r *rateRing) sum(window time.Duration) (requests, errors, bytes, latencyNs uint64) {
+	n := int(window / rateBucketWidth)
+	if n <= 0 {
+		n = 1
+	}
+	if n > rateBucketCount {
+		n = rateBucketCount
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idx := r.current
+	for i := 0; i < n; i++ {
+		b := r.buckets[idx]
+		requests += b.requests
+		errors += b.errors
+		bytes += b.bytes
+		latencyNs += b.latencyNs
+
+		idx--
+		if idx < 0 {
+			idx = rateBucketCount - 1
+		}
+	}
+
+	return
+}
+
+func (r *rateRing) rps(window time.Duration) float64 {
+	requests, _, _, _ := r.sum(window)
+	return float64(requests) / window.Seconds()
+}
+
+func (r *rateRing) errorRate(window time.Duration) float64 {
+	requests, errors, _, _ := r.sum(window)
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+func (r *rateRing) throughput(window time.Duration) float64 {
+	_, _, bytes, _ := r.sum(window)
+	return float64(bytes) / window.Seconds()
+}
+
+// RPS returns requests/sec over the trailing window.
+func (mw *Stats) RPS(window time.Duration) float64 { return mw.rates.rps(window) }
+
+// ErrorRate returns the fraction (0..1) of requests in the trailing window
+// that counted as errors (status >= 500).
+func (mw *Stats) ErrorRate(window time.Duration) float64 { return mw.rates.errorRate(window) }
+
+// Throughput returns response bytes/sec over the trailing window.
+func (mw *Stats) Throughput(window time.Duration) float64 { return mw.rates.throughput(window) }
@@ -0,0 +1,40 @@
+package stats
+
+import "net/http"
+
+// Recorder is what Begin hands back to callers so they can pass the
+// response status (and now size) to End without any bookkeeping of their
+// own.
+type Recorder interface {
+	http.ResponseWriter
+	Status() int
+	Size() int64
+}
+
+// RecorderResponseWriter wraps an http.ResponseWriter, capturing the status
+// code and the number of bytes written so both are available once the
+// handler returns.
+type RecorderResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *RecorderResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *RecorderResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RecorderResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *RecorderResponseWriter) Size() int64 {
+	return w.size
+}
@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+	"github.com/zenazn/goji/web"
+)
+
+// RouteResolver maps an incoming request to the label it should be tracked
+// under, so that e.g. /users/42/posts/1337 and /users/7/posts/9 collapse
+// into a single route instead of exploding URLRequestCounts per unique URL.
+type RouteResolver interface {
+	Resolve(r *http.Request) string
+}
+
+type gojiContextKeyType struct{}
+
+var gojiContextKey = gojiContextKeyType{}
+
+// GojiRouteResolver returns the matched route pattern from a goji web.C,
+// falling back to the raw path when no goji context is present. Handler
+// stashes the *web.C on the request context so Resolve can stay a plain
+// func(*http.Request) string like every other resolver.
+type GojiRouteResolver struct{}
+
+func (GojiRouteResolver) Resolve(r *http.Request) string {
+	c, ok := r.Context().Value(gojiContextKey).(*web.C)
+	if !ok || c == nil {
+		return r.URL.Path
+	}
+
+	if pattern, ok := c.Env["pattern"].(string); ok && pattern != "" {
+		return pattern
+	}
+
+	return r.URL.Path
+}
+
+// ChiRouteResolver returns the matched chi route pattern.
+type ChiRouteResolver struct{}
+
+func (ChiRouteResolver) Resolve(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return r.URL.Path
+}
+
+// GorillaRouteResolver returns the matched gorilla/mux path template.
+type GorillaRouteResolver struct{}
+
+func (GorillaRouteResolver) Resolve(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil && tpl != "" {
+			return tpl
+		}
+	}
+
+	return r.URL.Path
+}
+
+var (
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	uuidSegment    = regexp.MustCompile(`(?i)^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+// RegexRouteResolver collapses numeric and UUID path segments down to
+// :id/:uuid placeholders without needing any router integration. It's the
+// sensible default when the framework in use isn't goji, chi, or gorilla.
+type RegexRouteResolver struct{}
+
+func (RegexRouteResolver) Resolve(r *http.Request) string {
+	segments := strings.Split(r.URL.Path, "/")
+
+	for i, segment := range segments {
+		switch {
+		case numericSegment.MatchString(segment):
+			segments[i] = ":id"
+		case uuidSegment.MatchString(segment):
+			segments[i] = ":uuid"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// resolveRoute maps a request to its tracked route label, using Resolver
+// when one is configured and falling back to the legacy raw-URL behavior
+// otherwise.
+func (mw *Stats) resolveRoute(r *http.Request) string {
+	if mw.Resolver == nil {
+		return r.URL.String()
+	}
+
+	return mw.Resolver.Resolve(r)
+}
+
+// withGojiContext attaches c to r so GojiRouteResolver can recover it later,
+// since goji passes web.C alongside the request rather than through it.
+func withGojiContext(r *http.Request, c *web.C) *http.Request {
+	if c == nil {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), gojiContextKey, c))
+}
@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"container/list"
+	"sync"
+)
+
+// routeLRU tracks recency of use for every route key touched, so Stats can
+// cap the number of distinct routes it keeps per-route data for. It has its
+// own lock so tracking a route doesn't contend with the sharded counters or
+// Stats.mu.
+type routeLRU struct {
+	mu    sync.Mutex
+	order *list.List
+	index map[string]*list.Element
+}
+
+func newRouteLRU() *routeLRU {
+	return &routeLRU{
+		order: list.New(),
+		index: map[string]*list.Element{},
+	}
+}
+
+// touch marks route as most-recently-used, then calls evict for every
+// least-recently-used route beyond max. A max <= 0 means unlimited.
+func (rl *routeLRU) touch(route string, max int, evict func(string)) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if el, ok := rl.index[route]; ok {
+		rl.order.MoveToFront(el)
+	} else {
+		rl.index[route] = rl.order.PushFront(route)
+	}
+
+	if max <= 0 {
+		return
+	}
+
+	for rl.order.Len() > max {
+		oldest := rl.order.Back()
+		if oldest == nil {
+			break
+		}
+
+		oldestRoute := oldest.Value.(string)
+		rl.order.Remove(oldest)
+		delete(rl.index, oldestRoute)
+
+		evict(oldestRoute)
+	}
+}
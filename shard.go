@@ -0,0 +1,158 @@
+package stats
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Per-URL data used to live in flat maps guarded by Stats.mu, which meant
+// every request serialized on a single lock even though it only ever
+// touched its own URL's entries. shardedRoutes stripes that state across a
+// fixed number of independently-locked shards so unrelated routes stop
+// contending with each other.
+const routeShardCount = 64
+
+type routeShard struct {
+	mu                sync.Mutex
+	requestCounts     map[string]int
+	requestLatencyNs  map[string]int
+	highestResponseNs map[string]float64
+	lowestResponseNs  map[string]float64
+	latencyHistogram  map[string]*latencyHistogram
+	responseSizeBytes map[string]uint64
+}
+
+func newRouteShard() *routeShard {
+	return &routeShard{
+		requestCounts:     map[string]int{},
+		requestLatencyNs:  map[string]int{},
+		highestResponseNs: map[string]float64{},
+		lowestResponseNs:  map[string]float64{},
+		latencyHistogram:  map[string]*latencyHistogram{},
+		responseSizeBytes: map[string]uint64{},
+	}
+}
+
+type shardedRoutes struct {
+	shards [routeShardCount]*routeShard
+}
+
+func newShardedRoutes() *shardedRoutes {
+	sr := &shardedRoutes{}
+	for i := range sr.shards {
+		sr.shards[i] = newRouteShard()
+	}
+	return sr
+}
+
+func (sr *shardedRoutes) shardFor(url string) *routeShard {
+	h := fnv.New32a()
+	h.Write([]byte(url))
+	return sr.shards[h.Sum32()%routeShardCount]
+}
+
+func (sr *shardedRoutes) record(url string, responseTime time.Duration, size int64) {
+	shard := sr.shardFor(url)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.requestCounts[url]++
+	shard.requestLatencyNs[url] += int(responseTime)
+
+	ns := float64(responseTime)
+	if shard.highestResponseNs[url] < ns {
+		shard.highestResponseNs[url] = ns
+	}
+	if shard.lowestResponseNs[url] == 0 || ns < shard.lowestResponseNs[url] {
+		shard.lowestResponseNs[url] = ns
+	}
+
+	if shard.latencyHistogram[url] == nil {
+		shard.latencyHistogram[url] = newLatencyHistogram()
+	}
+	shard.latencyHistogram[url].observe(responseTime)
+
+	if size > 0 {
+		shard.responseSizeBytes[url] += uint64(size)
+	}
+}
+
+func (sr *shardedRoutes) evict(url string) {
+	shard := sr.shardFor(url)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	delete(shard.requestCounts, url)
+	delete(shard.requestLatencyNs, url)
+	delete(shard.highestResponseNs, url)
+	delete(shard.lowestResponseNs, url)
+	delete(shard.latencyHistogram, url)
+	delete(shard.responseSizeBytes, url)
+}
+
+// resetHistograms zeroes every per-route latency histogram in place,
+// without discarding the other counters tracked alongside them.
+func (sr *shardedRoutes) resetHistograms() {
+	for _, shard := range sr.shards {
+		shard.mu.Lock()
+		for _, h := range shard.latencyHistogram {
+			h.reset()
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// routeSnapshot is a point-in-time merge of every shard, used by Data() and
+// the Prometheus exporter.
+type routeSnapshot struct {
+	requestCounts     map[string]int
+	requestLatencyNs  map[string]int
+	highestResponseNs map[string]float64
+	lowestResponseNs  map[string]float64
+	latencyHistogram  map[string]*latencyHistogram
+	responseSizeBytes map[string]uint64
+}
+
+func (sr *shardedRoutes) snapshot() routeSnapshot {
+	snap := routeSnapshot{
+		requestCounts:     map[string]int{},
+		requestLatencyNs:  map[string]int{},
+		highestResponseNs: map[string]float64{},
+		lowestResponseNs:  map[string]float64{},
+		latencyHistogram:  map[string]*latencyHistogram{},
+		responseSizeBytes: map[string]uint64{},
+	}
+
+	for _, shard := range sr.shards {
+		shard.mu.Lock()
+
+		for url, c := range shard.requestCounts {
+			snap.requestCounts[url] = c
+		}
+		for url, l := range shard.requestLatencyNs {
+			snap.requestLatencyNs[url] = l
+		}
+		for url, v := range shard.highestResponseNs {
+			snap.highestResponseNs[url] = v
+		}
+		for url, v := range shard.lowestResponseNs {
+			snap.lowestResponseNs[url] = v
+		}
+		for url, h := range shard.latencyHistogram {
+			// Clone while the shard lock is held: record() keeps mutating
+			// this histogram in place, so handing out the live pointer
+			// would let readers race with it after we unlock.
+			snap.latencyHistogram[url] = h.clone()
+		}
+		for url, v := range shard.responseSizeBytes {
+			snap.responseSizeBytes[url] = v
+		}
+
+		shard.mu.Unlock()
+	}
+
+	return snap
+}
@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"context"
+	"time"
+)
+
+// Sink is a pluggable long-term retention backend. Stats drains observed
+// requests to every configured sink on a background goroutine, turning the
+// package from an in-process JSON/Prometheus dashboard into something that
+// can also feed a real metrics pipeline.
+type Sink interface {
+	ObserveRequest(labels map[string]string, latency time.Duration, size int64)
+	Flush(ctx context.Context) error
+}
+
+// Option configures a Stats instance at construction time.
+type Option func(*Stats)
+
+// WithSink registers a Sink to receive every observed request. Multiple
+// sinks can be registered by passing WithSink more than once.
+func WithSink(sink Sink) Option {
+	return func(st *Stats) {
+		st.sinks = append(st.sinks, sink)
+	}
+}
+
+// WithFlushInterval overrides how often registered sinks are flushed.
+// Defaults to 10s.
+func WithFlushInterval(d time.Duration) Option {
+	return func(st *Stats) {
+		st.flushInterval = d
+	}
+}
+
+const defaultFlushInterval = 10 * time.Second
+
+// sinkQueueSize bounds the event queue so a slow or stalled sink can never
+// make EndWith block on the request path; once full, new events are
+// dropped rather than queued.
+const sinkQueueSize = 4096
+
+type sinkEvent struct {
+	Labels  map[string]string
+	Latency time.Duration
+	Size    int64
+}
+
+// enqueueSinkEvent is the only thing EndWith does to talk to sinks: a
+// non-blocking send into a bounded queue. There's nothing to do when no
+// sinks are configured, so skip building the event entirely.
+func (mw *Stats) enqueueSinkEvent(labels map[string]string, latency time.Duration, size int64) {
+	if len(mw.sinks) == 0 {
+		return
+	}
+
+	select {
+	case mw.sinkEvents <- sinkEvent{Labels: labels, Latency: latency, Size: size}:
+	default:
+	}
+}
+
+// drainSinks fans queued events out to every configured sink and flushes
+// them on flushInterval. It owns the only receive end of sinkEvents, so it
+// runs for the lifetime of the Stats instance.
+func (mw *Stats) drainSinks() {
+	ticker := time.NewTicker(mw.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-mw.sinkEvents:
+			for _, sink := range mw.sinks {
+				sink.ObserveRequest(event.Labels, event.Latency, event.Size)
+			}
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), mw.flushInterval)
+			for _, sink := range mw.sinks {
+				sink.Flush(ctx)
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InfluxDBSink batches samples as InfluxDB line protocol and POSTs them to
+// a write endpoint on Flush.
+type InfluxDBSink struct {
+	client   *http.Client
+	writeURL string
+
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+// NewInfluxDBSink targets writeURL, the full InfluxDB write endpoint
+// (e.g. "http://localhost:8086/api/v2/write?org=o&bucket=b").
+func NewInfluxDBSink(writeURL string) *InfluxDBSink {
+	return &InfluxDBSink{
+		client:   http.DefaultClient,
+		writeURL: writeURL,
+	}
+}
+
+func (s *InfluxDBSink) ObserveRequest(labels map[string]string, latency time.Duration, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(&s.buf, "http_request,method=%s,path=%s,code=%s latency_ns=%di,size_bytes=%di %d\n",
+		influxEscape(labels["method"]), influxEscape(labels["path"]), influxEscape(labels["code"]),
+		latency.Nanoseconds(), size, time.Now().UnixNano())
+}
+
+func (s *InfluxDBSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	body := s.buf.String()
+	s.buf.Reset()
+	s.mu.Unlock()
+
+	if body == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.writeURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("stats: influxdb write failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+var influxEscaper = strings.NewReplacer(" ", "\\ ", ",", "\\,", "=", "\\=")
+
+func influxEscape(v string) string {
+	return influxEscaper.Replace(v)
+}
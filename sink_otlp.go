@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OTLPSink batches samples and POSTs them as an OTLP/HTTP metrics payload
+// (the JSON encoding of opentelemetry.proto.collector.metrics.v1) on
+// Flush. It depends only on net/http and encoding/json rather than pulling
+// in the full OpenTelemetry SDK.
+type OTLPSink struct {
+	client   *http.Client
+	endpoint string
+
+	mu     sync.Mutex
+	points []otlpDataPoint
+}
+
+type otlpDataPoint struct {
+	labels       map[string]string
+	latencySec   float64
+	timeUnixNano int64
+}
+
+// NewOTLPSink targets endpoint, the OTLP/HTTP metrics endpoint
+// (e.g. "http://localhost:4318/v1/metrics").
+func NewOTLPSink(endpoint string) *OTLPSink {
+	return &OTLPSink{
+		client:   http.DefaultClient,
+		endpoint: endpoint,
+	}
+}
+
+func (s *OTLPSink) ObserveRequest(labels map[string]string, latency time.Duration, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.points = append(s.points, otlpDataPoint{
+		labels:       labels,
+		latencySec:   latency.Seconds(),
+		timeUnixNano: time.Now().UnixNano(),
+	})
+}
+
+func (s *OTLPSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	points := s.points
+	s.points = nil
+	s.mu.Unlock()
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(otlpMetricsPayload(points))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("stats: otlp export failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// otlpMetricsPayload builds the minimal ExportMetricsServiceRequest JSON
+// shape needed to carry one gauge metric with per-point attributes.
+func otlpMetricsPayload(points []otlpDataPoint) map[string]interface{} {
+	dataPoints := make([]map[string]interface{}, 0, len(points))
+
+	for _, p := range points {
+		attributes := make([]map[string]interface{}, 0, len(p.labels))
+		for k, v := range p.labels {
+			attributes = append(attributes, map[string]interface{}{
+				"key":   k,
+				"value": map[string]interface{}{"stringValue": v},
+			})
+		}
+
+		dataPoints = append(dataPoints, map[string]interface{}{
+			"attributes":   attributes,
+			"timeUnixNano": fmt.Sprintf("%d", p.timeUnixNano),
+			"asDouble":     p.latencySec,
+		})
+	}
+
+	return map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{
+			{
+				"scopeMetrics": []map[string]interface{}{
+					{
+						"metrics": []map[string]interface{}{
+							{
+								"name": "http.server.request.duration",
+								"unit": "s",
+								"gauge": map[string]interface{}{
+									"dataPoints": dataPoints,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
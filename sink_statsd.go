@@ -0,0 +1,64 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// StatsDSink writes latency and size samples as StatsD UDP line protocol.
+// UDP is fire-and-forget, so Flush is a no-op.
+type StatsDSink struct {
+	conn   *net.UDPConn
+	prefix string
+}
+
+// NewStatsDSink dials addr (host:port) over UDP. prefix is prepended to
+// every metric name, e.g. "myapp".
+func NewStatsDSink(addr, prefix string) (*StatsDSink, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatsDSink{conn: conn, prefix: prefix}, nil
+}
+
+func (s *StatsDSink) ObserveRequest(labels map[string]string, latency time.Duration, size int64) {
+	metric := s.metricName(labels)
+
+	fmt.Fprintf(s.conn, "%s.latency_ms:%d|ms\n", metric, latency.Milliseconds())
+
+	if size > 0 {
+		fmt.Fprintf(s.conn, "%s.size_bytes:%d|c\n", metric, size)
+	}
+}
+
+func (s *StatsDSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *StatsDSink) metricName(labels map[string]string) string {
+	method := sanitizeStatsDSegment(labels["method"])
+	path := sanitizeStatsDSegment(labels["path"])
+
+	return fmt.Sprintf("%s.%s.%s", s.prefix, method, path)
+}
+
+var statsDSegmentReplacer = strings.NewReplacer("/", "_", ":", "_", " ", "_")
+
+func sanitizeStatsDSegment(segment string) string {
+	segment = statsDSegmentReplacer.Replace(segment)
+	segment = strings.Trim(segment, "_")
+	if segment == "" {
+		return "root"
+	}
+	return segment
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"github.com/zenazn/goji/web"
 )
@@ -32,30 +34,56 @@ type Stats struct {
 	Pid                 int
 	ResponseCounts      map[string]int
 	TotalResponseCounts map[string]int
-	URLRequestLatency   map[string]int
-	URLRequestCounts    map[string]int
 	TotalResponseTime   time.Time
 	RequestTypeCounts	map[string]int
 	UserAgentCounts		map[string]int
-	URLHighestResponse  map[string]float64
-	URLLowestResponse   map[string]float64
 	MaxResponseTime		*ResponseURL
+	GlobalLatencyHistogram *latencyHistogram
+	Quantiles              map[string]float64
+	rates                  *rateRing
+	RequestLabelCounts     map[string]uint64
+	Resolver               RouteResolver
+	MaxTrackedRoutes       int
+	routes                 *routeLRU
+	LabelCounts            map[string]uint64
+	routeShards            *shardedRoutes
+	TotalCount             atomic.Uint64
+	StatusClassCounts      [6]atomic.Uint64 // index 1..5 used for 1xx..5xx
+	sinks                  []Sink
+	sinkEvents             chan sinkEvent
+	flushInterval          time.Duration
 }
 
-func New() *Stats {
+// defaultMaxTrackedRoutes bounds the route LRU by default so an
+// unauthenticated client can't grow the per-route maps without limit just
+// by hitting a lot of distinct, attacker-chosen URLs. Set mw.MaxTrackedRoutes
+// to 0 after New to opt back into unbounded tracking.
+const defaultMaxTrackedRoutes = 10000
+
+func New(opts ...Option) *Stats {
 	stats := &Stats{
 		Uptime:              time.Now(),
 		Pid:                 os.Getpid(),
 		ResponseCounts:      map[string]int{},
 		TotalResponseCounts: map[string]int{},
-		URLRequestCounts:    map[string]int{},
-		URLRequestLatency:   map[string]int{},
 		TotalResponseTime:   time.Time{},
 		RequestTypeCounts:	 map[string]int{},
 		UserAgentCounts:	 map[string]int{},
-		URLHighestResponse:  map[string]float64{},
-		URLLowestResponse:   map[string]float64{},
 		MaxResponseTime:     &ResponseURL{ ResponseURL: "", ResponseDuration: 0, ResponseTime: time.Time{}, ResponseSeconds: 0.0, ResponseSince: &TimeSpan{} },
+		GlobalLatencyHistogram: newLatencyHistogram(),
+		Quantiles:              map[string]float64{"p50": 0.50, "p90": 0.90, "p95": 0.95, "p99": 0.99},
+		rates:                  newRateRing(),
+		RequestLabelCounts:     map[string]uint64{},
+		routes:                 newRouteLRU(),
+		MaxTrackedRoutes:       defaultMaxTrackedRoutes,
+		LabelCounts:            map[string]uint64{},
+		routeShards:            newShardedRoutes(),
+		sinkEvents:             make(chan sinkEvent, sinkQueueSize),
+		flushInterval:          defaultFlushInterval,
+	}
+
+	for _, opt := range opts {
+		opt(stats)
 	}
 
 	go func() {
@@ -66,23 +94,50 @@ func New() *Stats {
 		}
 	}()
 
+	go func() {
+		for {
+			time.Sleep(rateBucketWidth)
+
+			stats.rates.advance()
+		}
+	}()
+
+	if len(stats.sinks) > 0 {
+		go stats.drainSinks()
+	}
+
 	return stats
 }
 
+// ResetResponseCounts zeroes the per-second status code snapshot. Kept for
+// backward compatibility; RPS/ErrorRate/Throughput backed by the rate ring
+// are the more useful way to get this signal going forward.
 func (mw *Stats) ResetResponseCounts() {
 	mw.mu.Lock()
 	defer mw.mu.Unlock()
 	mw.ResponseCounts = map[string]int{}
 }
 
+// Reset zeroes the global and per-route latency histograms, discarding
+// accumulated distribution data without touching counts, rates, or labels.
+func (mw *Stats) Reset() {
+	mw.mu.Lock()
+	mw.GlobalLatencyHistogram.reset()
+	mw.mu.Unlock()
+
+	mw.routeShards.resetHistograms()
+}
+
 // MiddlewareFunc makes Stats implement the Middleware interface.
 func (mw *Stats) Handler( c *web.C, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		beginning, recorder := mw.Begin(w)
 
+		r = withGojiContext(r, c)
+
 		h.ServeHTTP(recorder, r)
 
-		mw.End(beginning, recorder, r.URL.String(), r.Method, r.UserAgent() )
+		mw.End(beginning, recorder, mw.resolveRoute(r), r.Method, r.UserAgent() )
 	})
 }
 
@@ -92,7 +147,7 @@ func (mw *Stats) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Han
 
 	next(recorder, r)
 
-	mw.End(beginning, recorder, r.URL.String(), r.Method, r.UserAgent() )
+	mw.End(beginning, recorder, mw.resolveRoute(r), r.Method, r.UserAgent() )
 }
 
 func (mw *Stats) Begin(w http.ResponseWriter) (time.Time, Recorder) {
@@ -103,24 +158,51 @@ func (mw *Stats) Begin(w http.ResponseWriter) (time.Time, Recorder) {
 	return start, writer
 }
 
-func (mw *Stats) EndWithStatus(start time.Time, status int, url string, method string, useragent string ) {
+// EndOptions carries everything EndWith needs to record a finished request.
+// It supersedes the long positional EndWithStatus signature and leaves room
+// to grow (Size, Labels) without another signature change.
+type EndOptions struct {
+	StatusCode int
+	Size       int64
+	URL        string
+	Method     string
+	UserAgent  string
+	Labels     map[string]string
+}
+
+// EndWith records a finished request described by opts. End and
+// EndWithStatus are kept as thin wrappers around it for backward
+// compatibility.
+func (mw *Stats) EndWith(start time.Time, opts EndOptions) {
 	end := time.Now()
 
 	responseTime := end.Sub(start)
 
-	mw.mu.Lock()
+	url := opts.URL
+	method := opts.Method
 
-	defer mw.mu.Unlock()
+	statusCode := fmt.Sprintf("%d", opts.StatusCode)
 
-	statusCode := fmt.Sprintf("%d", status)
+	// The hottest state - per-URL counts, latency, and histograms - lives in
+	// sharded/atomic storage outside mw.mu. The global maps and histogram
+	// below are still updated under mw.mu on every request.
+	mw.routeShards.record( url, responseTime, opts.Size )
+	mw.rates.record( opts.StatusCode >= 500, opts.Size, responseTime )
+
+	mw.TotalCount.Add(1)
+	if class := opts.StatusCode / 100; class >= 1 && class <= 5 {
+		mw.StatusClassCounts[class].Add(1)
+	}
+
+	mw.mu.Lock()
 
 	mw.ResponseCounts[statusCode]++
 	mw.TotalResponseCounts[statusCode]++
 	mw.TotalResponseTime = mw.TotalResponseTime.Add( responseTime )
-	mw.URLRequestCounts[url]++
-	mw.URLRequestLatency[url] += int( responseTime )
 	mw.RequestTypeCounts[method]++
-	mw.UserAgentCounts[useragent]++
+	mw.UserAgentCounts[opts.UserAgent]++
+	mw.GlobalLatencyHistogram.observe( responseTime )
+	mw.RequestLabelCounts[requestLabelKey( method, statusCode, url )]++
 
 	if mw.MaxResponseTime.ResponseDuration < responseTime {
 		mw.MaxResponseTime.ResponseDuration = responseTime
@@ -129,13 +211,63 @@ func (mw *Stats) EndWithStatus(start time.Time, status int, url string, method s
 		mw.MaxResponseTime.RepsonseMethod = method
 	}
 
-	if mw.URLHighestResponse[url] < float64( responseTime ) { mw.URLHighestResponse[url] = float64( responseTime )}
-	if mw.URLLowestResponse[url] == float64( 0 ) { mw.URLLowestResponse[url] = float64( responseTime )}
-	if float64( responseTime ) < mw.URLLowestResponse[url] { mw.URLLowestResponse[url] = float64( responseTime )}
+	for label, value := range opts.Labels {
+		mw.LabelCounts[label+"="+value]++
+	}
+
+	mw.mu.Unlock()
+
+	mw.routes.touch( url, mw.MaxTrackedRoutes, mw.evictRoute )
+
+	if len(mw.sinks) > 0 {
+		sinkLabels := map[string]string{"method": method, "path": url, "code": statusCode}
+		for label, value := range opts.Labels {
+			sinkLabels[label] = value
+		}
+		mw.enqueueSinkEvent( sinkLabels, responseTime, opts.Size )
+	}
+}
+
+// evictRoute drops every trace of route from the sharded counters and the
+// label-joined counters once it falls out of the LRU's tracked set.
+func (mw *Stats) evictRoute(route string) {
+	mw.routeShards.evict(route)
+
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	suffix := "|" + route
+	for key := range mw.RequestLabelCounts {
+		if strings.HasSuffix(key, suffix) {
+			delete(mw.RequestLabelCounts, key)
+		}
+	}
+}
+
+// requestLabelKey joins the method/status/path dimensions of a request into
+// a single map key, used to back the Prometheus method+code+path metric
+// without carrying a nested map per URL.
+func requestLabelKey(method, statusCode, url string) string {
+	return method + "|" + statusCode + "|" + url
+}
+
+func (mw *Stats) EndWithStatus(start time.Time, status int, url string, method string, useragent string ) {
+	mw.EndWith(start, EndOptions{
+		StatusCode: status,
+		URL:        url,
+		Method:     method,
+		UserAgent:  useragent,
+	})
 }
 
 func (mw *Stats) End(start time.Time, recorder Recorder, url string, method string, useragent string ) {
-	mw.EndWithStatus(start, recorder.Status(), url, method, useragent )
+	mw.EndWith(start, EndOptions{
+		StatusCode: recorder.Status(),
+		Size:       recorder.Size(),
+		URL:        url,
+		Method:     method,
+		UserAgent:  useragent,
+	})
 }
 
 type data struct {
@@ -159,6 +291,14 @@ type data struct {
 	URLHighestResponse     map[string]float64 `xml:"URLHighestResponse" json:"URLHighestResponse"`
 	URLLowestResponse      map[string]float64 `xml:"URLLowestResponse" json:"URLLowestResponse"`
 	MaxResponseTime	   	   *ResponseURL   `xml:"MaxResponseTimes" json:"MaxResponseTimes"`
+	URLLatencyQuantiles    map[string]map[string]float64 `xml:"URLLatencyQuantiles" json:"URLLatencyQuantiles"`
+	GlobalLatencyQuantiles map[string]float64             `xml:"GlobalLatencyQuantiles" json:"GlobalLatencyQuantiles"`
+	RPS10s1m5m15m          [4]float64 `xml:"RPS10s1m5m15m" json:"RPS10s1m5m15m"`
+	ErrorRate10s1m5m15m    [4]float64 `xml:"ErrorRate10s1m5m15m" json:"ErrorRate10s1m5m15m"`
+	Throughput10s1m5m15m   [4]float64 `xml:"Throughput10s1m5m15m" json:"Throughput10s1m5m15m"`
+	ResponseSizeBytes      map[string]uint64 `xml:"ResponseSizeBytes" json:"ResponseSizeBytes"`
+	LabelCounts            map[string]uint64 `xml:"LabelCounts" json:"LabelCounts"`
+	StatusClassCounts      map[string]uint64 `xml:"StatusClassCounts" json:"StatusClassCounts"`
 }
 
 func (mw *Stats) Data() *data {
@@ -174,10 +314,7 @@ func (mw *Stats) Data() *data {
 		count += current
 	}
 
-	totalCount := 0
-	for _, count := range mw.TotalResponseCounts {
-		totalCount += count
-	}
+	totalCount := int(mw.TotalCount.Load())
 
 	totalResponseTime := mw.TotalResponseTime.Sub(time.Time{})
 
@@ -197,6 +334,25 @@ func (mw *Stats) Data() *data {
 	mw.MaxResponseTime.ResponseSince.Minutes = minutes
 	mw.MaxResponseTime.ResponseSince.Seconds = seconds
 
+	routes := mw.routeShards.snapshot()
+
+	urlQuantiles := make(map[string]map[string]float64, len(routes.latencyHistogram))
+	for url, h := range routes.latencyHistogram {
+		urlQuantiles[url] = h.snapshot(mw.Quantiles)
+	}
+	globalQuantiles := mw.GlobalLatencyHistogram.snapshot(mw.Quantiles)
+
+	var rps, errorRate, throughput [4]float64
+	for i, window := range rateWindows {
+		rps[i] = mw.rates.rps(window)
+		errorRate[i] = mw.rates.errorRate(window)
+		throughput[i] = mw.rates.throughput(window)
+	}
+
+	statusClassCounts := make(map[string]uint64, 5)
+	for class := 1; class <= 5; class++ {
+		statusClassCounts[fmt.Sprintf("%dxx", class)] = mw.StatusClassCounts[class].Load()
+	}
 
 	r := &data{
 		Pid:                    mw.Pid,
@@ -212,13 +368,21 @@ func (mw *Stats) Data() *data {
 		TotalResponseTimeSec:   totalResponseTime.Seconds(),
 		AverageResponseTime:    averageResponseTime.String(),
 		AverageResponseTimeSec: averageResponseTime.Seconds(),
-		URLRequestCounts:       mw.URLRequestCounts,
+		URLRequestCounts:       routes.requestCounts,
 		RequestTypeCounts:		mw.RequestTypeCounts,
 		UserAgentCounts:		mw.UserAgentCounts,
-		URLRequestLatency:      mw.URLRequestLatency,
-		URLHighestResponse:     mw.URLHighestResponse,
-		URLLowestResponse:      mw.URLLowestResponse,
+		URLRequestLatency:      routes.requestLatencyNs,
+		URLHighestResponse:     routes.highestResponseNs,
+		URLLowestResponse:      routes.lowestResponseNs,
 		MaxResponseTime:		mw.MaxResponseTime,
+		URLLatencyQuantiles:    urlQuantiles,
+		GlobalLatencyQuantiles: globalQuantiles,
+		RPS10s1m5m15m:          rps,
+		ErrorRate10s1m5m15m:    errorRate,
+		Throughput10s1m5m15m:   throughput,
+		ResponseSizeBytes:      routes.responseSizeBytes,
+		LabelCounts:            mw.LabelCounts,
+		StatusClassCounts:      statusClassCounts,
 	}
 
 	mw.mu.RUnlock()
@@ -0,0 +1,30 @@
+package stats
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// BenchmarkParallelEnd exercises EndWith from many goroutines at once across
+// a spread of routes, which is the workload the sharded counters in
+// shard.go are there for.
+func BenchmarkParallelEnd(b *testing.B) {
+	mw := New()
+	start := time.Now()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			url := fmt.Sprintf("/users/%d", i%1000)
+			mw.EndWith(start, EndOptions{
+				StatusCode: 200,
+				URL:        url,
+				Method:     "GET",
+				UserAgent:  "bench",
+			})
+			i++
+		}
+	})
+}